@@ -3,11 +3,28 @@ package main
 import "github.com/ilyakaznacheev/cleanenv"
 
 type ConfigVars struct {
-	Port               string   `yaml:"port" env:"PORT" env-default:"5432"`
-	Host               string   `yaml:"host" env:"HOST" env-default:""`
-	NotionPollDuration string   `yaml:"notionPollDuration" env:"NOTION_POLL_DURATION" env-default:"6h"`
-	NotionAccessToken  string   `yaml:"notionAccessToken" env:"NOTION_TOKEN" env-default:""`
-	KnownDatabases     []string `yaml:"knownDatabases" env:"NOTION_KNOWN_DATABASES" env-default:""`
+	Port               string      `yaml:"port" env:"PORT" env-default:"5432"`
+	Host               string      `yaml:"host" env:"HOST" env-default:""`
+	NotionPollDuration string      `yaml:"notionPollDuration" env:"NOTION_POLL_DURATION" env-default:"6h"`
+	NotionAccessToken  string      `yaml:"notionAccessToken" env:"NOTION_TOKEN" env-default:""`
+	KnownDatabases     []string    `yaml:"knownDatabases" env:"NOTION_KNOWN_DATABASES" env-default:""`
+	WebhookStorePath   string      `yaml:"webhookStorePath" env:"WEBHOOK_STORE_PATH" env-default:"webhooks.json"`
+	Cache              CacheConfig `yaml:"cache"`
+}
+
+// CacheConfig selects and configures the Cache backend that Service stores
+// polled database snapshots in.
+type CacheConfig struct {
+	// Backend is "memory" (the default) or "bolt" for a disk-backed cache.
+	Backend string `yaml:"backend" env:"CACHE_BACKEND" env-default:"memory"`
+	// Path is the BoltDB file path, used only when Backend is "bolt".
+	Path string `yaml:"path" env:"CACHE_PATH" env-default:"cache.db"`
+	// StaleAfter is how long a cached snapshot is served as-is before
+	// QueryDatabaseCached starts refreshing it asynchronously.
+	StaleAfter string `yaml:"staleAfter" env:"CACHE_STALE_AFTER" env-default:"10m"`
+	// HardTTL is how long a cached snapshot is served at all before
+	// QueryDatabaseCached blocks on a synchronous refresh.
+	HardTTL string `yaml:"hardTtl" env:"CACHE_HARD_TTL" env-default:"24h"`
 }
 
 // ReadConfig loads configuration from the specified file path
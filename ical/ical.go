@@ -0,0 +1,111 @@
+// Package ical renders a queried Notion database as an RFC 5545 calendar
+// feed, mapping named properties (a title, a date, optionally a description
+// and a URL) onto VEVENT fields.
+package ical
+
+import (
+	"strings"
+	"time"
+
+	goical "github.com/emersion/go-ical"
+	"github.com/vegaprotocol/notion-data-service/notion"
+)
+
+// FieldMap names the DataItem properties to read for each calendar field.
+// Title and Date are required; Description and URL are optional.
+type FieldMap struct {
+	Title       string
+	Date        string
+	Description string
+	URL         string
+}
+
+// Render builds a text/calendar feed out of items, skipping any item whose
+// Date property is missing or unparseable.
+func Render(items []notion.DataItem, fields FieldMap) (string, error) {
+	cal := goical.NewCalendar()
+	cal.Props.SetText(goical.PropVersion, "2.0")
+	cal.Props.SetText(goical.PropProductID, "-//vegaprotocol//notion-data-service//EN")
+
+	for _, item := range items {
+		event, ok := renderEvent(item, fields)
+		if !ok {
+			continue
+		}
+		cal.Children = append(cal.Children, event.Component)
+	}
+
+	var buf strings.Builder
+	if err := goical.NewEncoder(&buf).Encode(cal); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func renderEvent(item notion.DataItem, fields FieldMap) (*goical.Event, bool) {
+	dateProp, ok := findProperty(item, fields.Date)
+	if !ok || len(dateProp.Values) == 0 {
+		return nil, false
+	}
+
+	start, allDay, err := parseDate(dateProp.Values[0])
+	if err != nil {
+		return nil, false
+	}
+
+	event := goical.NewEvent()
+	event.Props.SetText(goical.PropUID, item.ID+"@notion-data-service")
+	event.Props.SetDateTime(goical.PropDateTimeStamp, item.LastUpdated)
+	event.Props.SetDateTime(goical.PropLastModified, item.LastUpdated)
+	setDate(event, goical.PropDateTimeStart, start, allDay)
+
+	if len(dateProp.Values) > 1 {
+		if end, endAllDay, err := parseDate(dateProp.Values[1]); err == nil {
+			setDate(event, goical.PropDateTimeEnd, end, endAllDay)
+		}
+	}
+
+	if titleProp, ok := findProperty(item, fields.Title); ok {
+		event.Props.SetText(goical.PropSummary, strings.Join(titleProp.Values, ""))
+	}
+	if fields.Description != "" {
+		if descProp, ok := findProperty(item, fields.Description); ok {
+			event.Props.SetText(goical.PropDescription, strings.Join(descProp.Values, " "))
+		}
+	}
+	if fields.URL != "" {
+		if urlProp, ok := findProperty(item, fields.URL); ok && len(urlProp.Values) > 0 {
+			event.Props.SetText(goical.PropURL, urlProp.Values[0])
+		}
+	}
+
+	return event, true
+}
+
+func setDate(event *goical.Event, prop string, t time.Time, allDay bool) {
+	if allDay {
+		event.Props.SetDate(prop, t)
+		return
+	}
+	event.Props.SetDateTime(prop, t)
+}
+
+func findProperty(item notion.DataItem, name string) (notion.DataProperty, bool) {
+	for _, p := range item.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return notion.DataProperty{}, false
+}
+
+// parseDate parses a Notion date property value, which is either a plain
+// date (all-day) or a full RFC 3339 timestamp.
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
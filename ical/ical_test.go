@@ -0,0 +1,62 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vegaprotocol/notion-data-service/notion"
+)
+
+func TestParseDateAllDay(t *testing.T) {
+	got, allDay, err := parseDate("2023-06-15")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !allDay {
+		t.Fatalf("expected an all-day date to be detected")
+	}
+	if got.Format("2006-01-02") != "2023-06-15" {
+		t.Fatalf("unexpected date: %v", got)
+	}
+}
+
+func TestParseDateTimed(t *testing.T) {
+	got, allDay, err := parseDate("2023-06-15T09:30:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allDay {
+		t.Fatalf("expected a timed value not to be treated as all-day")
+	}
+	if got.Hour() != 9 || got.Minute() != 30 {
+		t.Fatalf("unexpected time: %v", got)
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	if _, _, err := parseDate("not-a-date"); err == nil {
+		t.Fatalf("expected an error for an unparseable date")
+	}
+}
+
+func TestRenderProducesAValidFeed(t *testing.T) {
+	items := []notion.DataItem{
+		{
+			ID:          "abc123",
+			LastUpdated: time.Date(2023, 6, 15, 9, 0, 0, 0, time.UTC),
+			Properties: []notion.DataProperty{
+				{Name: "Name", Values: []string{"Launch"}},
+				{Name: "Date", Values: []string{"2023-06-15"}},
+			},
+		},
+	}
+
+	feed, err := Render(items, FieldMap{Title: "Name", Date: "Date"})
+	if err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+	if !strings.Contains(feed, "DTSTAMP") {
+		t.Fatalf("expected the rendered feed to include a DTSTAMP property, got:\n%s", feed)
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
-	"os/signal"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	"github.com/vegaprotocol/notion-data-service/ical"
 	"github.com/vegaprotocol/notion-data-service/notion"
+	"github.com/vegaprotocol/notion-data-service/notion/boltcache"
+	"github.com/vegaprotocol/notion-data-service/shutdown"
 	"github.com/vegaprotocol/notion-data-service/util"
+	"github.com/vegaprotocol/notion-data-service/util/middleware"
 )
 
 func main() {
@@ -45,10 +51,41 @@ func startService(conf ConfigVars) {
 		pollDuration = 5 * time.Minute
 	}
 
+	staleAfter, err := time.ParseDuration(conf.Cache.StaleAfter)
+	if err != nil {
+		log.WithError(err).Warnf("Could not parse the cache staleAfter %s", conf.Cache.StaleAfter)
+		log.Warn("Using default stale-after of 10 minutes")
+		staleAfter = 10 * time.Minute
+	}
+
+	hardTTL, err := time.ParseDuration(conf.Cache.HardTTL)
+	if err != nil {
+		log.WithError(err).Warnf("Could not parse the cache hardTtl %s", conf.Cache.HardTTL)
+		log.Warn("Using default hard TTL of 24 hours")
+		hardTTL = 24 * time.Hour
+	}
+
 	log.Infof("Polling Notion.so every %s", pollDuration)
 	log.Infof("API binding to %s:%s", conf.Host, conf.Port)
 
-	notionService := notion.NewDataService(conf.NotionAccessToken, pollDuration, conf.KnownDatabases)
+	notionService := notion.NewDataService(conf.NotionAccessToken, pollDuration, staleAfter, hardTTL, conf.KnownDatabases)
+	if err := notionService.EnableWebhooks(conf.WebhookStorePath); err != nil {
+		log.WithError(err).Warn("Failed to enable webhook subscriptions")
+	}
+
+	switch conf.Cache.Backend {
+	case "", "memory":
+		// Service already defaults to an in-memory cache.
+	case "bolt":
+		cache, err := boltcache.NewCache(conf.Cache.Path)
+		if err != nil {
+			log.WithError(err).Fatalf("Failed to open bolt cache at %s", conf.Cache.Path)
+		}
+		notionService.UseCache(cache)
+		log.Infof("Using persistent bolt cache at %s", conf.Cache.Path)
+	default:
+		log.Warnf("Unknown cache backend %q, falling back to in-memory", conf.Cache.Backend)
+	}
 
 	router := mux.NewRouter()
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -63,24 +100,36 @@ func startService(conf ConfigVars) {
 	})
 	router.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
 		QueryHandler(w, r, notionService)
-	})
+	}).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		SubscribeHandler(w, r, notionService)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/ical", func(w http.ResponseWriter, r *http.Request) {
+		ICalHandler(w, r, notionService)
+	}).Methods(http.MethodGet)
+	router.Handle("/metrics", promhttp.Handler())
 
 	srv := &http.Server{
 		Addr:         conf.Host + ":" + conf.Port,
 		WriteTimeout: time.Second * 15,
 		ReadTimeout:  time.Second * 15,
 		IdleTimeout:  time.Second * 60,
-		Handler:      handlers.CORS(handlers.AllowedOrigins([]string{"*"}))(router),
+		Handler:      middleware.Logging(handlers.CORS(handlers.AllowedOrigins([]string{"*"}))(router)),
 	}
 
+	// mgr cancels its Context on SIGINT, SIGTERM or SIGQUIT, so the service
+	// shuts down cleanly both from an interactive Ctrl+C and under
+	// Docker/Kubernetes, which send SIGTERM.
+	mgr := shutdown.NewManager()
+
 	// Start contributor service
 	go func() {
-		notionService.Start()
+		notionService.Start(mgr.Context())
 	}()
 
 	// Start cleanup loop
 	go func() {
-		notionService.CleanupLoop()
+		notionService.CleanupLoop(mgr.Context())
 	}()
 
 	// Start api web service
@@ -90,28 +139,22 @@ func startService(conf ConfigVars) {
 		}
 	}()
 
-	c := make(chan os.Signal, 1)
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-	signal.Notify(c, os.Interrupt)
+	// Block until we receive a shutdown signal.
+	mgr.Wait()
 
-	// Block until we receive our signal.
-	<-c
+	// Signal to stop the contributor service, then wait for the cleanup
+	// loop to actually exit before moving on.
+	notionService.Stop()
+	<-notionService.CleanupDone()
 
-	// Create a deadline to wait for (15 seconds).
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	// Create a hard deadline to wait for (15 seconds).
+	hammerCtx, cancel := mgr.HammerContext(time.Second * 15)
 	defer cancel()
 
-	// Signal to stop the contributor service
-	notionService.Stop()
-
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
-	srv.Shutdown(ctx)
+	srv.Shutdown(hammerCtx)
 
-	// Optionally, you could run srv.Shutdown in a goroutine and block on
-	// <-ctx.Done() if your application should wait for other services
-	// to finalize based on context cancellation.
 	log.Info("Shutting down Notion.so data API service")
 	os.Exit(0)
 }
@@ -128,15 +171,48 @@ func QueryHandler(w http.ResponseWriter, r *http.Request, s *notion.Service) {
 		return
 	}
 
-	dataItems, err := s.QueryDatabaseCached(id)
-	if len(noCache) > 0 {
-		dataItems, err = s.QueryDatabase(id, true)
+	opts, err := parseQueryOptions(r)
+	if err != nil {
+		log.WithError(err).Error("Failed to parse query options")
+		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
 
-	response := DataResponse{
-		LastUpdated: time.Now().Unix(),
-		Items:       dataItems,
+	var response DataResponse
+
+	if !opts.IsEmpty() {
+		var result *notion.QueryResult
+		if len(noCache) > 0 {
+			result, err = s.QueryDatabasePage(r.Context(), id, opts)
+		} else {
+			result, err = s.QueryDatabasePageCached(r.Context(), id, opts)
+		}
+		if err == nil {
+			response = DataResponse{
+				LastUpdated: time.Now().Unix(),
+				Items:       result.Items,
+				NextCursor:  result.NextCursor,
+				HasMore:     result.HasMore,
+			}
+		}
+	} else {
+		var dataItems []notion.DataItem
+		dataItems, err = s.QueryDatabaseCached(r.Context(), id)
+		if len(noCache) > 0 {
+			dataItems, err = s.QueryDatabase(r.Context(), id, true)
+		}
+		response = DataResponse{
+			LastUpdated: time.Now().Unix(),
+			Items:       dataItems,
+		}
 	}
+
+	if err != nil {
+		log.WithError(err).Errorf("Failed to query notion database %s", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	payload, err := json.Marshal(response)
 	if err != nil {
 		log.WithError(err).Error("Failed to marshal payload for databases")
@@ -148,6 +224,144 @@ func QueryHandler(w http.ResponseWriter, r *http.Request, s *notion.Service) {
 	w.Write(payload)
 }
 
+// parseQueryOptions builds a notion.QueryOptions from the request, accepting
+// either a JSON POST body (filter, sorts, page_size, start_cursor) or the
+// equivalent query string parameters, to mirror Notion's own
+// QueryDatabase(ctx, id, *DatabaseQuery) shape.
+func parseQueryOptions(r *http.Request) (notion.QueryOptions, error) {
+	var opts notion.QueryOptions
+
+	if r.Method == http.MethodPost && r.Body != nil {
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		if err := dec.Decode(&opts); err != nil && err != io.EOF {
+			return opts, fmt.Errorf("invalid query body: %w", err)
+		}
+	}
+
+	if filter := util.GetQuery(r, "filter"); len(filter) > 0 {
+		opts.Filter = json.RawMessage(filter)
+	}
+	if sorts := util.GetQuery(r, "sorts"); len(sorts) > 0 {
+		if err := json.Unmarshal([]byte(sorts), &opts.Sorts); err != nil {
+			return opts, fmt.Errorf("invalid sorts param: %w", err)
+		}
+	}
+	if pageSize := util.GetQueryInt(r, "page_size"); pageSize > 0 {
+		opts.PageSize = int(pageSize)
+	}
+	if startCursor := util.GetQuery(r, "start_cursor"); len(startCursor) > 0 {
+		opts.StartCursor = startCursor
+	}
+
+	return opts, nil
+}
+
+// ICalHandler renders a queried database as an RFC 5545 text/calendar feed.
+// title and date name the DataItem properties to use for SUMMARY and
+// DTSTART/DTEND; description and url are optional.
+func ICalHandler(w http.ResponseWriter, r *http.Request, s *notion.Service) {
+	id := util.GetQuery(r, "id")
+	titleProp := util.GetQuery(r, "title")
+	dateProp := util.GetQuery(r, "date")
+
+	if len(id) < 10 || len(titleProp) == 0 || len(dateProp) == 0 {
+		log.Errorf("Invalid ical request: id=%s title=%s date=%s", id, titleProp, dateProp)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.QueryDatabaseCached(r.Context(), id)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to query notion database %s for ical feed", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	etag := icalETag(items)
+	if match := r.Header.Get("If-None-Match"); len(match) > 0 && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	feed, err := ical.Render(items, ical.FieldMap{
+		Title:       titleProp,
+		Date:        dateProp,
+		Description: util.GetQuery(r, "description"),
+		URL:         util.GetQuery(r, "url"),
+	})
+	if err != nil {
+		log.WithError(err).Errorf("Failed to render ical feed for %s", id)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(s.PollDuration().Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(feed))
+}
+
+// icalETag is derived from the newest LastUpdated across all items, so the
+// feed only changes when the underlying data actually does.
+func icalETag(items []notion.DataItem) string {
+	var newest time.Time
+	for _, item := range items {
+		if item.LastUpdated.After(newest) {
+			newest = item.LastUpdated
+		}
+	}
+	return strconv.Quote(strconv.FormatInt(newest.Unix(), 10))
+}
+
+// SubscribeRequest is the POST /subscribe body: register callbackURL to
+// receive a signed diff whenever databaseID's polled snapshot changes.
+type SubscribeRequest struct {
+	DatabaseID  string `json:"database_id"`
+	CallbackURL string `json:"callback_url"`
+	Secret      string `json:"secret"`
+}
+
+type SubscribeResponse struct {
+	ID string `json:"id"`
+}
+
+func SubscribeHandler(w http.ResponseWriter, r *http.Request, s *notion.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req SubscribeRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithError(err).Error("Failed to decode subscribe request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if len(req.DatabaseID) < 10 || len(req.CallbackURL) == 0 {
+		log.Errorf("Invalid subscribe request: database_id=%s callback_url=%s", req.DatabaseID, req.CallbackURL)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.Subscribe(req.DatabaseID, req.CallbackURL, req.Secret)
+	if err != nil {
+		log.WithError(err).Error("Failed to create webhook subscription")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	payload, err := json.Marshal(SubscribeResponse{ID: sub.ID})
+	if err != nil {
+		log.WithError(err).Error("Failed to marshal subscribe response")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
 func ListHandler(w http.ResponseWriter, r *http.Request, s *notion.Service) {
 	w.Header().Set("Content-Type", "application/json")
 	dbs := s.ListDatabases()
@@ -199,4 +413,6 @@ type ListResponse struct {
 type DataResponse struct {
 	LastUpdated int64             `json:"last_updated"`
 	Items       []notion.DataItem `json:"notion_data"`
+	NextCursor  string            `json:"next_cursor,omitempty"`
+	HasMore     bool              `json:"has_more,omitempty"`
 }
@@ -0,0 +1,68 @@
+// Package metrics holds the Prometheus collectors shared across the
+// service, so notion.Service and util/middleware can record to the same
+// registry that main.go exposes on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// NotionAPICallsTotal counts every call made to the Notion API, labeled
+	// by database ID and outcome ("success" or "error"). Callers must use
+	// Service.metricDatabaseLabel rather than the raw database ID, since
+	// /query and /subscribe accept caller-supplied IDs that would otherwise
+	// be an unbounded label source.
+	NotionAPICallsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notion_api_calls_total",
+		Help: "Notion API calls made by the service, labeled by database ID (bounded to known databases) and outcome.",
+	}, []string{"database_id", "outcome"})
+
+	// NotionAPIRetriesTotal counts Notion API calls retried after a 429
+	// response, labeled by database ID. See NotionAPICallsTotal on bounding
+	// that label.
+	NotionAPIRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notion_api_retries_total",
+		Help: "Notion API calls retried after a 429 (rate limited) response, labeled by database ID (bounded to known databases).",
+	}, []string{"database_id"})
+
+	// PollDurationSeconds is how long one full update() cycle takes across
+	// every known database.
+	PollDurationSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "notion_poll_duration_seconds",
+		Help:    "Time taken to poll every known database during one update cycle.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// QueryCacheResultsTotal counts QueryDatabaseCached outcomes, labeled by
+	// result: "hit" (fresh snapshot), "stale" (served stale, async refresh
+	// triggered) or "miss" (blocked on a synchronous refresh).
+	QueryCacheResultsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notion_query_cache_results_total",
+		Help: "QueryDatabaseCached results, labeled by hit/stale/miss.",
+	}, []string{"result"})
+
+	// IgnoredDatabases is how many databases are currently in the
+	// ignored set.
+	IgnoredDatabases = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "notion_ignored_databases",
+		Help: "Number of databases currently in the ignored set.",
+	})
+
+	// HTTPRequestDuration is per-endpoint HTTP request latency, labeled by
+	// method, path and status.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, labeled by method, path and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+
+	// HTTPResponseSize is the response body size in bytes, labeled the same
+	// way as HTTPRequestDuration.
+	HTTPResponseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "HTTP response size in bytes, labeled by method, path and status.",
+		Buckets: prometheus.ExponentialBuckets(128, 4, 8),
+	}, []string{"method", "path", "status"})
+)
@@ -0,0 +1,96 @@
+// Package boltcache is a disk-backed notion.Cache implementation, so
+// database snapshots survive a restart instead of requiring a cold-start
+// stampede against Notion's rate-limited API, and /query keeps serving the
+// last known-good data through a Notion outage.
+package boltcache
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/vegaprotocol/notion-data-service/notion"
+)
+
+var bucketName = []byte("databases")
+
+// record is what gets persisted per database: the last snapshot together
+// with when it was fetched, so Cache.Get can report its age.
+type record struct {
+	Items     []notion.DataItem `json:"items"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Cache is a notion.Cache backed by a single BoltDB file.
+type Cache struct {
+	db *bolt.DB
+}
+
+// NewCache opens (creating if necessary) a BoltDB file at path and returns a
+// Cache backed by it.
+func NewCache(path string) (*Cache, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Cache{db: db}, nil
+}
+
+func (c *Cache) Get(databaseID string) ([]notion.DataItem, time.Time, bool) {
+	var rec record
+	found := false
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketName).Get([]byte(databaseID))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &rec)
+	})
+	if err != nil || !found {
+		return nil, time.Time{}, false
+	}
+
+	return rec.Items, rec.UpdatedAt, true
+}
+
+func (c *Cache) Put(databaseID string, items []notion.DataItem, updatedAt time.Time) {
+	data, err := json.Marshal(record{Items: items, UpdatedAt: updatedAt})
+	if err != nil {
+		return
+	}
+
+	c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(databaseID), data)
+	})
+}
+
+func (c *Cache) List() []string {
+	var result []string
+
+	c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(k, _ []byte) error {
+			result = append(result, string(k))
+			return nil
+		})
+	})
+
+	return result
+}
+
+func (c *Cache) Delete(databaseID string) {
+	c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(databaseID))
+	})
+}
@@ -0,0 +1,75 @@
+package notion
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache stores polled database snapshots, keyed by Notion database ID, so
+// Service can be backed by plain memory or by a persistent store without
+// changing any polling or query logic. Get reports when a snapshot was
+// fetched so callers can apply their own staleness rules.
+type Cache interface {
+	// Get returns the cached items for databaseID and when they were
+	// fetched, or ok=false if nothing is cached for it yet.
+	Get(databaseID string) (items []DataItem, updatedAt time.Time, ok bool)
+	// Put stores items for databaseID as having been fetched at updatedAt.
+	Put(databaseID string, items []DataItem, updatedAt time.Time)
+	// List returns every database ID currently in the cache.
+	List() []string
+	// Delete removes databaseID from the cache.
+	Delete(databaseID string)
+}
+
+type memoryCacheEntry struct {
+	items     []DataItem
+	updatedAt time.Time
+}
+
+// MemoryCache is the default Cache: an in-memory map guarded by a mutex,
+// with nothing persisted across restarts.
+type MemoryCache struct {
+	mu    sync.RWMutex
+	items map[string]memoryCacheEntry
+}
+
+// NewMemoryCache returns an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{items: map[string]memoryCacheEntry{}}
+}
+
+func (c *MemoryCache) Get(databaseID string) ([]DataItem, time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.items[databaseID]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return entry.items, entry.updatedAt, true
+}
+
+func (c *MemoryCache) Put(databaseID string, items []DataItem, updatedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[databaseID] = memoryCacheEntry{items: items, updatedAt: updatedAt}
+}
+
+func (c *MemoryCache) List() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]string, 0, len(c.items))
+	for databaseID := range c.items {
+		result = append(result, databaseID)
+	}
+	return result
+}
+
+func (c *MemoryCache) Delete(databaseID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, databaseID)
+}
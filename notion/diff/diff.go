@@ -0,0 +1,93 @@
+// Package diff computes added/removed/modified changes between two
+// snapshots of a Notion database's data items. It knows nothing about the
+// notion package itself so that notion.Service (which depends on it) can't
+// form an import cycle.
+package diff
+
+import "reflect"
+
+// Item is a minimal, comparable view of a Notion database row.
+type Item struct {
+	ID         string
+	Properties map[string][]string
+}
+
+// PropertyChange describes how a single property changed on a modified item.
+type PropertyChange struct {
+	Name   string   `json:"name"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// ItemChange describes a modified item: its ID and the properties that differ.
+type ItemChange struct {
+	ID         string           `json:"id"`
+	Properties []PropertyChange `json:"properties"`
+}
+
+// Changes is the result of comparing two snapshots.
+type Changes struct {
+	Added    []Item       `json:"added,omitempty"`
+	Removed  []Item       `json:"removed,omitempty"`
+	Modified []ItemChange `json:"modified,omitempty"`
+}
+
+// IsEmpty reports whether nothing changed between the two snapshots.
+func (c Changes) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// Compute diffs oldItems against newItems by item ID, reporting items that
+// were added, removed, or had at least one property value change.
+func Compute(oldItems, newItems []Item) Changes {
+	oldByID := make(map[string]Item, len(oldItems))
+	for _, item := range oldItems {
+		oldByID[item.ID] = item
+	}
+
+	newByID := make(map[string]Item, len(newItems))
+	for _, item := range newItems {
+		newByID[item.ID] = item
+	}
+
+	var changes Changes
+
+	for _, item := range newItems {
+		old, existed := oldByID[item.ID]
+		if !existed {
+			changes.Added = append(changes.Added, item)
+			continue
+		}
+
+		if propChanges := diffProperties(old.Properties, item.Properties); len(propChanges) > 0 {
+			changes.Modified = append(changes.Modified, ItemChange{ID: item.ID, Properties: propChanges})
+		}
+	}
+
+	for _, item := range oldItems {
+		if _, stillExists := newByID[item.ID]; !stillExists {
+			changes.Removed = append(changes.Removed, item)
+		}
+	}
+
+	return changes
+}
+
+func diffProperties(before, after map[string][]string) []PropertyChange {
+	names := make(map[string]struct{}, len(before)+len(after))
+	for name := range before {
+		names[name] = struct{}{}
+	}
+	for name := range after {
+		names[name] = struct{}{}
+	}
+
+	var changed []PropertyChange
+	for name := range names {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changed = append(changed, PropertyChange{Name: name, Before: before[name], After: after[name]})
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,52 @@
+package diff
+
+import "testing"
+
+func TestComputeAddedRemovedModified(t *testing.T) {
+	oldItems := []Item{
+		{ID: "1", Properties: map[string][]string{"Status": {"Todo"}}},
+		{ID: "2", Properties: map[string][]string{"Status": {"Done"}}},
+	}
+	newItems := []Item{
+		{ID: "1", Properties: map[string][]string{"Status": {"In Progress"}}},
+		{ID: "3", Properties: map[string][]string{"Status": {"Todo"}}},
+	}
+
+	changes := Compute(oldItems, newItems)
+
+	if len(changes.Added) != 1 || changes.Added[0].ID != "3" {
+		t.Fatalf("expected item 3 to be added, got %+v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0].ID != "2" {
+		t.Fatalf("expected item 2 to be removed, got %+v", changes.Removed)
+	}
+	if len(changes.Modified) != 1 || changes.Modified[0].ID != "1" {
+		t.Fatalf("expected item 1 to be modified, got %+v", changes.Modified)
+	}
+
+	propChanges := changes.Modified[0].Properties
+	if len(propChanges) != 1 || propChanges[0].Name != "Status" {
+		t.Fatalf("expected a single Status property change, got %+v", propChanges)
+	}
+	if propChanges[0].Before[0] != "Todo" || propChanges[0].After[0] != "In Progress" {
+		t.Fatalf("unexpected before/after values: %+v", propChanges[0])
+	}
+}
+
+func TestComputeNoChanges(t *testing.T) {
+	items := []Item{{ID: "1", Properties: map[string][]string{"Status": {"Todo"}}}}
+
+	changes := Compute(items, items)
+
+	if !changes.IsEmpty() {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestComputeEmptySnapshots(t *testing.T) {
+	changes := Compute(nil, nil)
+
+	if !changes.IsEmpty() {
+		t.Fatalf("expected no changes between two empty snapshots, got %+v", changes)
+	}
+}
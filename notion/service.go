@@ -1,8 +1,14 @@
 package notion
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,9 +16,19 @@ import (
 
 	jnotionapi "github.com/jomei/notionapi"
 	log "github.com/sirupsen/logrus"
-	"github.com/vegaprotocol/notion-data-service/util"
+	"github.com/vegaprotocol/notion-data-service/metrics"
+	"github.com/vegaprotocol/notion-data-service/notion/diff"
+	"github.com/vegaprotocol/notion-data-service/notion/webhook"
 )
 
+// MaxRetryAttempts caps how many times QueryDatabase retries a single page
+// fetch after a 429 (rate limited) response before giving up.
+const MaxRetryAttempts = 5
+
+// DefaultRetryBackoff is used when a 429 response carries no usable
+// Retry-After header.
+const DefaultRetryBackoff = 2 * time.Second
+
 // IgnoreDatabaseDuration define how often we can try to pull unknown databases
 // when database pull fails
 const IgnoreDatabaseDuration = 5 * time.Minute
@@ -28,69 +44,279 @@ type DataProperty struct {
 	Values []string `json:"values"` // One or more values, e.g. In Progress
 }
 
+// QueryOptions carries the Notion query parameters a caller of /query can
+// pass through to the underlying jnotionapi.DatabaseQueryRequest. Filter is
+// kept as raw JSON since Notion's filter object is a deeply nested union of
+// property-specific shapes that the caller already knows how to build.
+type QueryOptions struct {
+	Filter      json.RawMessage         `json:"filter,omitempty"`
+	Sorts       []jnotionapi.SortObject `json:"sorts,omitempty"`
+	PageSize    int                     `json:"page_size,omitempty"`
+	StartCursor string                  `json:"start_cursor,omitempty"`
+}
+
+// IsEmpty reports whether o carries no scoped-query parameters at all, i.e.
+// a caller that wants the plain, unscoped QueryDatabaseCached behavior.
+func (o QueryOptions) IsEmpty() bool {
+	return len(o.Filter) == 0 && len(o.Sorts) == 0 && o.PageSize == 0 && o.StartCursor == ""
+}
+
+// QueryResult is a single page of a scoped query, along with Notion's
+// pagination cursor so the caller can fetch the next page.
+type QueryResult struct {
+	Items      []DataItem `json:"items"`
+	NextCursor string     `json:"next_cursor"`
+	HasMore    bool       `json:"has_more"`
+}
+
+// QueryCacheDuration is how long a scoped query (filter/sorts/page) result is
+// served from cache before it is re-fetched from Notion.
+const QueryCacheDuration = 1 * time.Minute
+
+type cachedQueryResult struct {
+	result    QueryResult
+	fetchedAt time.Time
+}
+
 type Service struct {
 	notionAccessToken string
-	databaseMap       map[string][]DataItem // ID -> DataItem
-	lastUpdated       time.Time
+	cache             Cache
 	pollDuration      time.Duration
+	staleAfter        time.Duration
+	hardTTL           time.Duration
 	timer             *time.Ticker
 
-	mu                   sync.RWMutex
 	ignoreDatabasesMutex sync.RWMutex
 	wipMutex             sync.Mutex
 
 	ignoredDatabases map[string]time.Time
 	knownDatabases   []string
+
+	queryCacheMutex sync.RWMutex
+	queryCache      map[string]cachedQueryResult
+
+	refreshingMutex sync.Mutex
+	refreshing      map[string]bool
+
+	webhookStore      *webhook.Store
+	webhookDispatcher *webhook.Dispatcher
+
+	ctx         context.Context
+	cleanupDone chan struct{}
 }
 
-func NewDataService(notionAccessToken string, pollDuration time.Duration, knownDatabases []string) *Service {
+// NewDataService builds a Service backed by an in-memory Cache. staleAfter
+// and hardTTL bound QueryDatabaseCached: within staleAfter a cached snapshot
+// is returned as-is, between staleAfter and hardTTL it is still returned but
+// a refresh is triggered asynchronously, and past hardTTL the caller blocks
+// on a synchronous refresh. Call UseCache before Start to swap in a
+// persistent backend instead.
+func NewDataService(notionAccessToken string, pollDuration, staleAfter, hardTTL time.Duration, knownDatabases []string) *Service {
 	svc := &Service{
 		notionAccessToken: notionAccessToken,
+		cache:             NewMemoryCache(),
 		pollDuration:      pollDuration,
+		staleAfter:        staleAfter,
+		hardTTL:           hardTTL,
 		ignoredDatabases:  map[string]time.Time{},
 		knownDatabases:    knownDatabases,
+		queryCache:        map[string]cachedQueryResult{},
+		refreshing:        map[string]bool{},
+		ctx:               context.Background(),
+		cleanupDone:       make(chan struct{}),
 	}
 	return svc
 }
 
-func (s *Service) Start() {
-	log.Info("Notion data service started")
+// UseCache swaps the snapshot cache backend, e.g. for a persistent,
+// disk-backed Cache. Call before Start.
+func (s *Service) UseCache(c Cache) {
+	s.cache = c
+}
+
+// EnableWebhooks turns on webhook/push subscriptions for this service,
+// loading any previously-registered subscriptions from webhookStorePath.
+func (s *Service) EnableWebhooks(webhookStorePath string) error {
+	store, err := webhook.NewStore(webhookStorePath)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook subscriptions: %w", err)
+	}
+
+	s.webhookStore = store
+	s.webhookDispatcher = webhook.NewDispatcher(webhook.DefaultWorkers)
+
+	return nil
+}
+
+// Subscribe registers a webhook that will be called with a signed diff
+// whenever the polled snapshot for databaseID changes. A database only joins
+// update()'s diff loop once it has a cached snapshot, so if databaseID has no
+// cached snapshot yet, Subscribe queries it first to both validate it's
+// reachable and add it to the polled set; otherwise the subscription would
+// register successfully but never see a delivery.
+func (s *Service) Subscribe(databaseID, callbackURL, secret string) (webhook.Subscription, error) {
+	if s.webhookStore == nil {
+		return webhook.Subscription{}, fmt.Errorf("webhooks are not enabled on this service")
+	}
+
+	cached := false
+	normalized := normalizedNotionID(databaseID)
+	for _, id := range s.ListDatabases() {
+		if normalizedNotionID(id) == normalized {
+			cached = true
+			break
+		}
+	}
+
+	if !cached {
+		log.Infof("Database %s has no cached snapshot yet, querying it so it joins the polled set", databaseID)
+		if _, err := s.QueryDatabase(s.ctx, databaseID, true); err != nil {
+			return webhook.Subscription{}, fmt.Errorf("cannot subscribe to unreachable database %s: %w", databaseID, err)
+		}
+	}
+
+	return s.webhookStore.Add(databaseID, callbackURL, secret)
+}
+
+// isKnownDatabase reports whether databaseID already has a cached snapshot or
+// is configured as one of the service's knownDatabases.
+func (s *Service) isKnownDatabase(databaseID string) bool {
+	normalized := normalizedNotionID(databaseID)
+
+	for _, id := range s.ListDatabases() {
+		if normalizedNotionID(id) == normalized {
+			return true
+		}
+	}
+	for _, id := range s.knownDatabases {
+		if normalizedNotionID(id) == normalized {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unvalidatedDatabaseLabel replaces the literal database ID in Prometheus
+// labels for any database that isn't one of the service's known ones, so a
+// caller hitting /query or /subscribe with arbitrary, unvalidated database
+// IDs can't each mint a fresh, unbounded label value.
+const unvalidatedDatabaseLabel = "unvalidated"
+
+// metricDatabaseLabel returns notionID unchanged if it's one of the
+// service's known databases, and unvalidatedDatabaseLabel otherwise, to
+// bound the cardinality of database-ID-labeled metrics.
+func (s *Service) metricDatabaseLabel(notionID string) string {
+	if s.isKnownDatabase(notionID) {
+		return notionID
+	}
+	return unvalidatedDatabaseLabel
+}
 
-	s.mu.Lock()
-	s.databaseMap = map[string][]DataItem{}
-	s.lastUpdated = time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)
-	s.mu.Unlock()
+// Start begins polling Notion until ctx is cancelled. The context is also
+// threaded into every Notion API call made during polling, so a shutdown
+// cancels in-flight requests instead of leaving them to run to completion.
+func (s *Service) Start(ctx context.Context) {
+	log.Info("Notion data service started")
 
+	s.ctx = ctx
 	s.update()
-	s.timer = util.Schedule(s.update, s.pollDuration)
+
+	s.timer = time.NewTicker(s.pollDuration)
+	defer s.timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Notion data service stopped")
+			return
+		case <-s.timer.C:
+			s.update()
+		}
+	}
 }
 
 func (s *Service) update() {
 	log.Info("Begin update of Notion.so data")
 
+	start := time.Now()
+	defer func() {
+		metrics.PollDurationSeconds.Observe(time.Since(start).Seconds())
+	}()
+
 	dbs := s.ListDatabases()
 	if len(dbs) == 0 {
 		log.Info("Service currently does not manage any notion database. Database is added when queried for a first time")
 		return
 	}
-	res := map[string][]DataItem{}
+
+	now := time.Now()
+	previous := map[string][]DataItem{}
+	current := map[string][]DataItem{}
+
 	for _, databaseID := range dbs {
 		log.Infof("Querying Notion.so for database %s", databaseID)
-		dataItems, err := s.QueryDatabase(databaseID, false)
+		dataItems, err := s.QueryDatabase(s.ctx, databaseID, false)
 		if err != nil {
 			log.WithError(err).Errorf("Failed to query notion database %s during update", databaseID)
-		} else {
-			res[databaseID] = dataItems
+			continue
+		}
+
+		if items, _, ok := s.cache.Get(databaseID); ok {
+			previous[databaseID] = items
 		}
+		s.cache.Put(databaseID, dataItems, now)
+		current[databaseID] = dataItems
 	}
 
-	s.mu.Lock()
-	s.databaseMap = res
-	s.mu.Unlock()
+	s.notifySubscribers(previous, current)
 
 	log.Info("Completed update of Notion.so data")
 }
 
+// notifySubscribers diffs the previous and current snapshot of every
+// database that has registered subscribers, and dispatches a delivery for
+// every database whose data actually changed.
+func (s *Service) notifySubscribers(previous, current map[string][]DataItem) {
+	if s.webhookStore == nil {
+		return
+	}
+
+	for databaseID, items := range current {
+		subs := s.webhookStore.ForDatabase(databaseID)
+		if len(subs) == 0 {
+			continue
+		}
+
+		changes := diff.Compute(toDiffItems(previous[databaseID]), toDiffItems(items))
+		if changes.IsEmpty() {
+			continue
+		}
+
+		for _, sub := range subs {
+			s.webhookDispatcher.Dispatch(sub, changes)
+		}
+	}
+}
+
+func toDiffItems(items []DataItem) []diff.Item {
+	result := make([]diff.Item, 0, len(items))
+	for _, item := range items {
+		properties := make(map[string][]string, len(item.Properties))
+		for _, prop := range item.Properties {
+			properties[prop.Name] = prop.Values
+		}
+		result = append(result, diff.Item{ID: item.ID, Properties: properties})
+	}
+	return result
+}
+
+// PollDuration returns how often this service refreshes its snapshots from
+// Notion, so callers can derive their own caching hints from it.
+func (s *Service) PollDuration() time.Duration {
+	return s.pollDuration
+}
+
 func (s *Service) Stop() {
 	if s.timer != nil {
 		s.timer.Stop()
@@ -102,26 +328,32 @@ func (s *Service) Stop() {
 // Instead of List, we construct list of the databases map in the Service struct.
 // Object is added to the database map when requested for a first time.
 func (s *Service) ListDatabases() []string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	result := []string{}
-
-	for databaseID, _ := range s.databaseMap {
-		result = append(result, databaseID)
-	}
-
-	return result
+	return s.cache.List()
 }
 
-func (s *Service) QueryDatabaseCached(notionID string) ([]DataItem, error) {
-	s.mu.RLock()
-	if val, ok := s.databaseMap[notionID]; ok {
-		log.Infof("The database %s fetched from cache", notionID)
-		s.mu.RUnlock()
-		return val, nil
+// QueryDatabaseCached serves a database snapshot from the Cache rather than
+// hitting Notion on every call. A snapshot younger than staleAfter is
+// returned as-is; one older than that but still within hardTTL is also
+// returned, but triggers an asynchronous refresh so the next call sees fresh
+// data; one older than hardTTL blocks on a synchronous refresh, falling back
+// to the stale snapshot (if any) when that refresh fails.
+func (s *Service) QueryDatabaseCached(ctx context.Context, notionID string) ([]DataItem, error) {
+	if items, updatedAt, ok := s.cache.Get(notionID); ok {
+		age := time.Since(updatedAt)
+		if age <= s.staleAfter {
+			log.Infof("The database %s fetched from cache", notionID)
+			metrics.QueryCacheResultsTotal.WithLabelValues("hit").Inc()
+			return items, nil
+		}
+		if age <= s.hardTTL {
+			log.Infof("The database %s fetched from stale cache, refreshing asynchronously", notionID)
+			metrics.QueryCacheResultsTotal.WithLabelValues("stale").Inc()
+			s.refreshAsync(notionID)
+			return items, nil
+		}
 	}
-	s.mu.RUnlock()
+
+	metrics.QueryCacheResultsTotal.WithLabelValues("miss").Inc()
 
 	// This lock must be very rare. Usually We hit it only when there is an error for Notion
 	s.wipMutex.Lock()
@@ -132,25 +364,59 @@ func (s *Service) QueryDatabaseCached(notionID string) ([]DataItem, error) {
 		return nil, fmt.Errorf("the %s database ignored", notionID)
 	}
 
-	// Check if another thread already fetched database
-	if val, ok := s.databaseMap[notionID]; ok {
-		return val, nil
+	// Check if another thread already refreshed the database while we
+	// waited for wipMutex.
+	if items, updatedAt, ok := s.cache.Get(notionID); ok && time.Since(updatedAt) <= s.staleAfter {
+		return items, nil
 	}
 
-	log.Warnf("Cannot find database with ID %s in cache, trying to query it", notionID)
-	res, err := s.QueryDatabase(notionID, true)
+	log.Warnf("Cannot find fresh database with ID %s in cache, trying to query it", notionID)
+	res, err := s.QueryDatabase(ctx, notionID, true)
 	if err != nil {
 		s.ignoreNotionDatabase(notionID)
+		if items, _, ok := s.cache.Get(notionID); ok {
+			log.Warnf("Serving stale cached data for %s after refresh failure", notionID)
+			return items, nil
+		}
 		return nil, fmt.Errorf("failed to query database %s: %w", notionID, err)
 	}
 
 	return res, nil
 }
 
-func (s *Service) QueryDatabase(notionID string, updateMapIfSuccess bool) ([]DataItem, error) {
+// refreshAsync refreshes notionID from Notion in the background, coalescing
+// concurrent requests for the same database into a single in-flight refresh.
+func (s *Service) refreshAsync(notionID string) {
+	s.refreshingMutex.Lock()
+	if s.refreshing[notionID] {
+		s.refreshingMutex.Unlock()
+		return
+	}
+	s.refreshing[notionID] = true
+	s.refreshingMutex.Unlock()
+
+	go func() {
+		defer func() {
+			s.refreshingMutex.Lock()
+			delete(s.refreshing, notionID)
+			s.refreshingMutex.Unlock()
+		}()
+
+		if _, err := s.QueryDatabase(s.ctx, notionID, true); err != nil {
+			log.WithError(err).Warnf("Failed to refresh stale database %s", notionID)
+		}
+	}()
+}
+
+// QueryDatabase pages through the whole database, issuing every
+// client.Database.Query call with ctx so a shutdown (or any other caller
+// cancellation) aborts the in-flight request instead of running it to
+// completion.
+func (s *Service) QueryDatabase(ctx context.Context, notionID string, updateMapIfSuccess bool) ([]DataItem, error) {
 	dbID := jnotionapi.DatabaseID(notionID)
 	token := jnotionapi.Token(s.notionAccessToken)
-	client := jnotionapi.NewClient(token)
+	rt := newRetryAfterTransport()
+	client := jnotionapi.NewClient(token, jnotionapi.WithHTTPClient(&http.Client{Transport: rt}))
 
 	result := []DataItem{}
 	var nextCursor jnotionapi.Cursor
@@ -160,15 +426,19 @@ func (s *Service) QueryDatabase(notionID string, updateMapIfSuccess bool) ([]Dat
 			StartCursor: nextCursor,
 			PageSize:    100,
 		}
-		page, err := client.Database.Query(context.Background(), dbID, &queryReq)
+		page, err := s.queryWithRetry(ctx, rt, notionID, func(ctx context.Context) (*jnotionapi.DatabaseQueryResponse, error) {
+			return client.Database.Query(ctx, dbID, &queryReq)
+		})
 
 		if err != nil {
 			log.WithError(err).Errorf("Failed to query notion database %s via API for cursor: %s", notionID, nextCursor)
+			metrics.NotionAPICallsTotal.WithLabelValues(s.metricDatabaseLabel(notionID), "error").Inc()
 			return nil, err
 		}
+		metrics.NotionAPICallsTotal.WithLabelValues(s.metricDatabaseLabel(notionID), "success").Inc()
 		if page == nil {
-			log.WithError(err).Errorf("Failed to find page for notion database %s via API for cursor: %s", notionID, nextCursor)
-			return nil, err
+			log.Errorf("Failed to find page for notion database %s via API for cursor: %s", notionID, nextCursor)
+			return nil, fmt.Errorf("no page returned for notion database %s", notionID)
 		}
 		res := s.processPageProperties(page.Results)
 
@@ -181,9 +451,7 @@ func (s *Service) QueryDatabase(notionID string, updateMapIfSuccess bool) ([]Dat
 	}
 
 	if updateMapIfSuccess && len(result) > 0 {
-		s.mu.Lock()
-		s.databaseMap[notionID] = result
-		s.mu.Unlock()
+		s.cache.Put(notionID, result, time.Now())
 	}
 
 	log.Infof("Found and processed %d data items for %s database", len(result), notionID)
@@ -191,6 +459,260 @@ func (s *Service) QueryDatabase(notionID string, updateMapIfSuccess bool) ([]Dat
 	return result, nil
 }
 
+// queryWithRetry runs do, retrying on a 429 (rate limited) response up to
+// MaxRetryAttempts times. It waits for the duration Notion's Retry-After
+// header asked for, recovered via rt since jnotionapi's error type only
+// surfaces the JSON error body and drops HTTP headers; DefaultRetryBackoff is
+// used when that header is missing or unparseable. do is shared by
+// QueryDatabase (which goes through client.Database.Query) and
+// QueryDatabasePage (which can't, see doRawDatabaseQuery), so both get the
+// same retry/backoff behaviour.
+func (s *Service) queryWithRetry(ctx context.Context, rt *retryAfterTransport, notionID string, do func(ctx context.Context) (*jnotionapi.DatabaseQueryResponse, error)) (*jnotionapi.DatabaseQueryResponse, error) {
+	for attempt := 0; ; attempt++ {
+		page, err := do(ctx)
+
+		var apiErr *jnotionapi.Error
+		if err == nil || !errors.As(err, &apiErr) || apiErr.Status != http.StatusTooManyRequests || attempt >= MaxRetryAttempts {
+			return page, err
+		}
+
+		wait := rt.takeRetryAfter()
+		if wait <= 0 {
+			wait = DefaultRetryBackoff
+		}
+
+		metrics.NotionAPIRetriesTotal.WithLabelValues(s.metricDatabaseLabel(notionID)).Inc()
+		log.Warnf("Notion rate limited database %s query, retrying in %s (attempt %d/%d)", notionID, wait, attempt+1, MaxRetryAttempts)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryAfterTransport wraps the default transport to record the Retry-After
+// header of the most recent 429 response, since jnotionapi.Client's Error
+// type only carries the decoded JSON error body and drops HTTP headers.
+type retryAfterTransport struct {
+	base http.RoundTripper
+
+	mu         sync.Mutex
+	retryAfter time.Duration
+}
+
+func newRetryAfterTransport() *retryAfterTransport {
+	return &retryAfterTransport{base: http.DefaultTransport}
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err == nil && res.StatusCode == http.StatusTooManyRequests {
+		t.mu.Lock()
+		t.retryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+		t.mu.Unlock()
+	}
+	return res, err
+}
+
+// takeRetryAfter returns and clears the last recorded Retry-After duration.
+func (t *retryAfterTransport) takeRetryAfter() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wait := t.retryAfter
+	t.retryAfter = 0
+	return wait
+}
+
+// parseRetryAfter accepts both forms the header may take: a number of
+// seconds, or an HTTP date to wait until.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+	return 0
+}
+
+// QueryDatabasePage runs a single, scoped Notion query: it forwards the
+// caller's filter, sorts, page size and start cursor straight to Notion
+// instead of paging through the whole database like QueryDatabase does. It
+// goes through doRawDatabaseQuery rather than client.Database.Query because
+// jnotionapi.DatabaseQueryRequest can only marshal its own typed
+// PropertyFilter/CompoundFilter, not an arbitrary filter object like
+// opts.Filter. ctx is threaded through so a shutdown or client disconnect
+// cancels the request, and the call shares QueryDatabase's retry/backoff and
+// metrics via queryWithRetry.
+func (s *Service) QueryDatabasePage(ctx context.Context, notionID string, opts QueryOptions) (*QueryResult, error) {
+	if s.IsDatabaseIgnored(notionID) {
+		return nil, fmt.Errorf("the %s database ignored", notionID)
+	}
+
+	dbID := jnotionapi.DatabaseID(notionID)
+	token := jnotionapi.Token(s.notionAccessToken)
+	rt := newRetryAfterTransport()
+	httpClient := &http.Client{Transport: rt}
+
+	if opts.PageSize <= 0 {
+		opts.PageSize = 100
+	}
+
+	page, err := s.queryWithRetry(ctx, rt, notionID, func(ctx context.Context) (*jnotionapi.DatabaseQueryResponse, error) {
+		return doRawDatabaseQuery(ctx, httpClient, token, dbID, opts)
+	})
+	if err != nil {
+		log.WithError(err).Errorf("Failed to query notion database %s via API for cursor: %s", notionID, opts.StartCursor)
+		metrics.NotionAPICallsTotal.WithLabelValues(s.metricDatabaseLabel(notionID), "error").Inc()
+
+		// A 400 means the caller's own filter/sorts/cursor was malformed,
+		// not that the database is unreachable - don't let one caller's bad
+		// request deny every other caller (including the plain, unscoped
+		// /query path) access to this database for IgnoreDatabaseDuration.
+		var apiErr *jnotionapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+			s.ignoreNotionDatabase(notionID)
+		}
+
+		return nil, err
+	}
+	metrics.NotionAPICallsTotal.WithLabelValues(s.metricDatabaseLabel(notionID), "success").Inc()
+	if page == nil {
+		return nil, fmt.Errorf("no page returned for notion database %s", notionID)
+	}
+
+	return &QueryResult{
+		Items:      s.processPageProperties(page.Results),
+		NextCursor: string(page.NextCursor),
+		HasMore:    page.HasMore,
+	}, nil
+}
+
+// QueryDatabasePageCached serves QueryDatabasePage results from a short-lived
+// cache keyed on the database ID plus the filter/sorts/cursor combination, so
+// repeated identical scoped queries don't hit Notion's rate limit.
+func (s *Service) QueryDatabasePageCached(ctx context.Context, notionID string, opts QueryOptions) (*QueryResult, error) {
+	key := queryCacheKey(notionID, opts)
+
+	s.queryCacheMutex.RLock()
+	cached, ok := s.queryCache[key]
+	s.queryCacheMutex.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < QueryCacheDuration {
+		log.Infof("The scoped query for database %s fetched from cache", notionID)
+		return &cached.result, nil
+	}
+
+	result, err := s.QueryDatabasePage(ctx, notionID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.queryCacheMutex.Lock()
+	s.queryCache[key] = cachedQueryResult{result: *result, fetchedAt: time.Now()}
+	s.queryCacheMutex.Unlock()
+
+	return result, nil
+}
+
+// rawDatabaseQueryRequest mirrors the wire format of
+// jnotionapi.DatabaseQueryRequest, except Filter is carried through as raw
+// JSON instead of being forced into PropertyFilter/CompoundFilter.
+type rawDatabaseQueryRequest struct {
+	Sorts       []jnotionapi.SortObject `json:"sorts,omitempty"`
+	StartCursor jnotionapi.Cursor       `json:"start_cursor,omitempty"`
+	PageSize    int                     `json:"page_size,omitempty"`
+	Filter      json.RawMessage         `json:"filter,omitempty"`
+}
+
+// notionAPIBaseURL and notionAPIVersion mirror the unexported constants
+// jnotionapi.Client builds its requests from.
+const (
+	notionAPIBaseURL = "https://api.notion.com/v1"
+	notionAPIVersion = "2021-08-16"
+)
+
+// doRawDatabaseQuery issues a database query directly over HTTP instead of
+// through client.Database.Query, mirroring what jnotionapi.Client does
+// internally, because DatabaseQueryRequest.MarshalJSON can only emit a
+// PropertyFilter or CompoundFilter and has no way to pass through an
+// arbitrary filter object such as opts.Filter.
+func doRawDatabaseQuery(ctx context.Context, httpClient *http.Client, token jnotionapi.Token, dbID jnotionapi.DatabaseID, opts QueryOptions) (*jnotionapi.DatabaseQueryResponse, error) {
+	body, err := json.Marshal(rawDatabaseQueryRequest{
+		Sorts:       opts.Sorts,
+		StartCursor: jnotionapi.Cursor(opts.StartCursor),
+		PageSize:    opts.PageSize,
+		Filter:      opts.Filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/databases/%s/query", notionAPIBaseURL, dbID.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.String())
+	req.Header.Set("Notion-Version", notionAPIVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var apiErr jnotionapi.Error
+		if err := json.NewDecoder(res.Body).Decode(&apiErr); err != nil {
+			return nil, err
+		}
+		return nil, &apiErr
+	}
+
+	var response jnotionapi.DatabaseQueryResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// queryCacheKey hashes the database ID together with the filter and sorts so
+// two different scoped queries against the same database never collide.
+func queryCacheKey(notionID string, opts QueryOptions) string {
+	h := sha256.New()
+	h.Write([]byte(notionID))
+	h.Write(opts.Filter)
+	if sortsJSON, err := json.Marshal(opts.Sorts); err == nil {
+		h.Write(sortsJSON)
+	}
+	h.Write([]byte(opts.StartCursor))
+	h.Write([]byte(strconv.Itoa(opts.PageSize)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// formatNotionDate renders a Notion date value as a plain "2006-01-02" date
+// when it has no time component, or as RFC3339 otherwise, so ical.parseDate
+// can tell an all-day value from a timed one. jnotionapi.Date is just a
+// time.Time under the hood and its own String() always formats through
+// RFC3339, which collapses that distinction (a date-only value and a
+// midnight-UTC datetime both become "...T00:00:00Z"); checking for a
+// midnight-UTC clock here is the best available signal, since jnotionapi
+// doesn't retain whether the original property was a date or a datetime.
+func formatNotionDate(d jnotionapi.Date) string {
+	t := time.Time(d)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
 func (s *Service) processPageProperties(pages []jnotionapi.Page) []DataItem {
 	res := make([]DataItem, 0)
 	for _, p := range pages {
@@ -227,10 +749,10 @@ func (s *Service) processPageProperties(pages []jnotionapi.Page) []DataItem {
 			dp, ok := p.(*jnotionapi.DateProperty)
 			if ok {
 				if dp.Date.Start != nil {
-					pr.Values = append(pr.Values, dp.Date.Start.String())
+					pr.Values = append(pr.Values, formatNotionDate(*dp.Date.Start))
 				}
 				if dp.Date.End != nil {
-					pr.Values = append(pr.Values, dp.Date.End.String())
+					pr.Values = append(pr.Values, formatNotionDate(*dp.Date.End))
 				}
 			}
 			sp, ok := p.(*jnotionapi.SelectProperty)
@@ -343,6 +865,7 @@ func (s *Service) ignoreNotionDatabase(notionID string) {
 
 	log.Infof("The %s database added to ignored set", notionID)
 	s.ignoredDatabases[normalizedID] = time.Now()
+	metrics.IgnoredDatabases.Set(float64(len(s.ignoredDatabases)))
 }
 
 func normalizedNotionID(notionID string) string {
@@ -350,11 +873,19 @@ func normalizedNotionID(notionID string) string {
 	return strings.ReplaceAll(notionID, "-", "")
 }
 
-func (s *Service) CleanupLoop() {
+// CleanupLoop periodically prunes expired entries from ignoredDatabases. It
+// runs until ctx is cancelled, closing CleanupDone on exit so a shutdown
+// manager can wait for it to actually stop before proceeding.
+func (s *Service) CleanupLoop(ctx context.Context) {
 	ticker := time.NewTicker(2 * time.Minute)
+	defer ticker.Stop()
+	defer close(s.cleanupDone)
 
 	for {
 		select {
+		case <-ctx.Done():
+			log.Info("Notion data service cleanup loop stopped")
+			return
 		case <-ticker.C:
 			s.ignoreDatabasesMutex.Lock()
 
@@ -367,7 +898,15 @@ func (s *Service) CleanupLoop() {
 				delete(s.ignoredDatabases, notionID)
 			}
 
+			metrics.IgnoredDatabases.Set(float64(len(s.ignoredDatabases)))
 			s.ignoreDatabasesMutex.Unlock()
 		}
 	}
 }
+
+// CleanupDone is closed once CleanupLoop has exited in response to its
+// context being cancelled, so a shutdown manager can wait for it before
+// tearing down anything the loop still depends on.
+func (s *Service) CleanupDone() <-chan struct{} {
+	return s.cleanupDone
+}
@@ -0,0 +1,30 @@
+package notion
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfterSeconds(t *testing.T) {
+	got := parseRetryAfter("5")
+	if got != 5*time.Second {
+		t.Fatalf("expected 5s, got %v", got)
+	}
+}
+
+func TestParseRetryAfterHTTPDate(t *testing.T) {
+	when := time.Now().Add(10 * time.Second).UTC()
+	got := parseRetryAfter(when.Format(http.TimeFormat))
+	if got <= 0 || got > 11*time.Second {
+		t.Fatalf("expected a wait close to 10s, got %v", got)
+	}
+}
+
+func TestParseRetryAfterEmptyOrInvalid(t *testing.T) {
+	for _, value := range []string{"", "not-a-duration"} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Fatalf("expected 0 for %q, got %v", value, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxDeliveryAttempts caps how many times a single delivery is attempted,
+// the first try included, before the dispatcher gives up on it.
+const MaxDeliveryAttempts = 5
+
+// DeliveryBackoff is the base delay between delivery attempts; it doubles on
+// every subsequent attempt.
+const DeliveryBackoff = time.Second
+
+// DefaultWorkers is how many deliveries the dispatcher processes concurrently.
+const DefaultWorkers = 4
+
+type delivery struct {
+	subscription Subscription
+	payload      interface{}
+}
+
+// Dispatcher delivers webhook payloads to subscribers from a worker pool,
+// retrying with exponential backoff and signing every payload with HMAC-SHA256.
+type Dispatcher struct {
+	jobs   chan delivery
+	client *http.Client
+}
+
+// NewDispatcher starts a dispatcher backed by the given number of workers.
+func NewDispatcher(workers int) *Dispatcher {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	d := &Dispatcher{
+		jobs:   make(chan delivery, 100),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+
+	return d
+}
+
+// Dispatch queues a payload for delivery to a subscription's callback URL.
+// Deliveries are queued for the workers, so the caller does not wait on the
+// network; once 100 deliveries are pending, it blocks until a worker frees
+// a slot.
+func (d *Dispatcher) Dispatch(sub Subscription, payload interface{}) {
+	d.jobs <- delivery{subscription: sub, payload: payload}
+}
+
+func (d *Dispatcher) worker() {
+	for job := range d.jobs {
+		d.deliver(job)
+	}
+}
+
+func (d *Dispatcher) deliver(job delivery) {
+	body, err := json.Marshal(job.payload)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to marshal webhook payload for %s", job.subscription.CallbackURL)
+		return
+	}
+
+	signature := sign(job.subscription.Secret, body)
+
+	for attempt := 1; attempt <= MaxDeliveryAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(DeliveryBackoff * time.Duration(1<<uint(attempt-2)))
+		}
+
+		if d.attemptDelivery(job.subscription.CallbackURL, signature, body) {
+			return
+		}
+
+		log.Warnf("Webhook delivery to %s failed (attempt %d/%d)", job.subscription.CallbackURL, attempt, MaxDeliveryAttempts)
+	}
+
+	log.Errorf("Giving up on webhook delivery to %s after %d attempts", job.subscription.CallbackURL, MaxDeliveryAttempts)
+}
+
+func (d *Dispatcher) attemptDelivery(callbackURL, signature string, body []byte) bool {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).Errorf("Failed to build webhook request for %s", callbackURL)
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notion-Signature", signature)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		log.WithError(err).Warnf("Webhook request to %s failed", callbackURL)
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode < 300
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
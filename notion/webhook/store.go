@@ -0,0 +1,142 @@
+// Package webhook lets clients register callback URLs that get a signed
+// notification whenever a polled Notion database changes, and takes care of
+// persisting those registrations and delivering the notifications.
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Subscription is a client's registration to be notified when a database changes.
+type Subscription struct {
+	ID          string    `json:"id"`
+	DatabaseID  string    `json:"database_id"`
+	CallbackURL string    `json:"callback_url"`
+	Secret      string    `json:"secret"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Store persists subscriptions to a JSON file so they survive restarts.
+type Store struct {
+	path string
+
+	mu   sync.RWMutex
+	subs map[string]Subscription // ID -> Subscription
+}
+
+// NewStore loads subscriptions from path if it exists, and will persist
+// future additions and removals back to it.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, subs: map[string]Subscription{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		s.subs[sub.ID] = sub
+	}
+
+	log.Infof("Loaded %d webhook subscription(s) from %s", len(s.subs), path)
+
+	return s, nil
+}
+
+// Add registers a new subscription and persists the store. databaseID is
+// normalized so ForDatabase matches it regardless of which ID format
+// (dashed or not) the caller and the poller each happen to use for the same
+// database.
+func (s *Store) Add(databaseID, callbackURL, secret string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := Subscription{
+		ID:          newSubscriptionID(databaseID, callbackURL),
+		DatabaseID:  normalizeDatabaseID(databaseID),
+		CallbackURL: callbackURL,
+		Secret:      secret,
+		CreatedAt:   time.Now(),
+	}
+	s.subs[sub.ID] = sub
+
+	if err := s.persistLocked(); err != nil {
+		return Subscription{}, err
+	}
+
+	return sub, nil
+}
+
+// Remove deletes a subscription by ID and persists the store.
+func (s *Store) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subs, id)
+
+	return s.persistLocked()
+}
+
+// ForDatabase returns all subscriptions registered against a database ID,
+// matching regardless of dashes/spaces in either ID (see normalizeDatabaseID).
+func (s *Store) ForDatabase(databaseID string) []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	normalized := normalizeDatabaseID(databaseID)
+
+	result := []Subscription{}
+	for _, sub := range s.subs {
+		if sub.DatabaseID == normalized {
+			result = append(result, sub)
+		}
+	}
+
+	return result
+}
+
+func (s *Store) persistLocked() error {
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+
+	data, err := json.Marshal(subs)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+func newSubscriptionID(databaseID, callbackURL string) string {
+	h := sha256.New()
+	h.Write([]byte(databaseID))
+	h.Write([]byte(callbackURL))
+	h.Write([]byte(strconv.FormatInt(time.Now().UnixNano(), 10)))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// normalizeDatabaseID strips the dashes and spaces Notion database IDs are
+// inconsistently formatted with, so the same database is recognized
+// regardless of which format a particular caller used.
+func normalizeDatabaseID(databaseID string) string {
+	databaseID = strings.ReplaceAll(databaseID, " ", "")
+	return strings.ReplaceAll(databaseID, "-", "")
+}
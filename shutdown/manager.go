@@ -0,0 +1,57 @@
+// Package shutdown provides a small graceful-shutdown manager: it derives a
+// cancellable context from OS signals so long-running work (the Notion
+// poller, the cleanup loop, in-flight API calls) can wind down cleanly
+// instead of being killed mid-request, and it hands out a hard-deadline
+// "hammer" context for the final, bounded cleanup step.
+package shutdown
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Manager cancels its Context the first time the process receives SIGINT,
+// SIGTERM or SIGQUIT, so it behaves correctly both from an interactive
+// Ctrl+C and under Docker/Kubernetes, which send SIGTERM.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	sig    chan os.Signal
+}
+
+// NewManager installs the signal handler and returns a Manager ready to
+// Wait on.
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		ctx:    ctx,
+		cancel: cancel,
+		sig:    make(chan os.Signal, 1),
+	}
+	signal.Notify(m.sig, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	return m
+}
+
+// Context is cancelled as soon as a shutdown signal is received. Pass it to
+// anything that should stop, or have its in-flight calls cancelled, on
+// shutdown.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Wait blocks until a shutdown signal is received, cancels Context and
+// returns.
+func (m *Manager) Wait() {
+	<-m.sig
+	m.cancel()
+}
+
+// HammerContext returns a context with a hard deadline of timeout, for
+// bounding whatever cleanup still has to run after Wait returns (e.g.
+// http.Server.Shutdown draining in-flight requests).
+func (m *Manager) HammerContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,79 @@
+// Package middleware holds cross-cutting net/http middleware shared by the
+// service's router.
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vegaprotocol/notion-data-service/metrics"
+)
+
+// responseRecorder wraps http.ResponseWriter to capture the status code and
+// byte count written, so Logging can report both once the handler returns.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// unmatchedPathLabel replaces the literal request path in Prometheus labels
+// for any request that falls through to the router's NotFoundHandler, so
+// clients probing arbitrary unmatched paths (trivial given CORS is "*")
+// can't each mint a fresh, unbounded label value.
+const unmatchedPathLabel = "unmatched"
+
+// Logging wraps next with a structured logrus entry per request (method,
+// path, status, duration, bytes written) and records the same dimensions as
+// Prometheus metrics, so successful requests are as observable as the 404s
+// NotFoundHandler already logs. The logged path is always the literal
+// request path; the Prometheus labels use unmatchedPathLabel instead for 404s
+// to bound their cardinality.
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next.ServeHTTP(rec, r)
+
+		duration := time.Since(start)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		log.WithFields(log.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   status,
+			"duration": duration.String(),
+			"bytes":    rec.bytes,
+		}).Info("Handled request")
+
+		path := r.URL.Path
+		if status == http.StatusNotFound {
+			path = unmatchedPathLabel
+		}
+
+		statusLabel := strconv.Itoa(status)
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, path, statusLabel).Observe(duration.Seconds())
+		metrics.HTTPResponseSize.WithLabelValues(r.Method, path, statusLabel).Observe(float64(rec.bytes))
+	})
+}